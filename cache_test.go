@@ -0,0 +1,108 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import "testing"
+
+type stubHashLoader struct {
+	*InMemLoader
+	hash string
+}
+
+func (l *stubHashLoader) Stat(path string) (string, error) {
+	return l.hash, nil
+}
+
+// cacheTemplate used to hash and index the serving loader under the raw,
+// extension-less lookup path (e.g. "index"), while getTemplateFromLoader
+// records the serving loader under the canonical, extension-resolved path
+// (e.g. "index.jet"). Since isStale looks the loader up by the template's
+// real ParseName, a freshly cached entry's version was always stamped "",
+// and the very next call found it stale and reparsed it — every time.
+func TestCacheTemplateUsesCanonicalParseName(t *testing.T) {
+	loader := &stubHashLoader{InMemLoader: NewInMemLoader(), hash: "v1"}
+	set := NewSetLoader(nil, loader)
+	set.templateLoader["index.jet"] = loader
+
+	tmpl := &Template{ParseName: "index.jet"}
+	set.cacheTemplate(tmpl)
+
+	entry, ok := set.cache["index.jet"]
+	if !ok {
+		t.Fatalf("expected a cache entry keyed by the canonical ParseName")
+	}
+	if entry.version != "v1" {
+		t.Fatalf("expected cached version %q, got %q", "v1", entry.version)
+	}
+	if set.isStale(entry) {
+		t.Fatalf("freshly cached entry must not be considered stale")
+	}
+}
+
+// recordDependents used to append to s.dependents on every cacheTemplate
+// call without pruning stale associations, so a template that was reparsed
+// with a different (or no) extends/import kept cascading invalidation to a
+// base it no longer actually depends on.
+func TestRecordDependentsPrunesStaleAssociationsOnReparse(t *testing.T) {
+	set := NewSetLoader(nil, NewInMemLoader())
+
+	base := &Template{ParseName: "base.jet"}
+	other := &Template{ParseName: "other.jet"}
+	child := &Template{ParseName: "child.jet", extends: base}
+
+	set.cacheTemplate(base)
+	set.cacheTemplate(other)
+	set.cacheTemplate(child)
+
+	if deps := set.dependents["base.jet"]; len(deps) != 1 || deps[0] != "child.jet" {
+		t.Fatalf("expected child.jet to depend on base.jet, got %v", deps)
+	}
+
+	// child.jet is reparsed, now extending other.jet instead of base.jet.
+	reparsedChild := &Template{ParseName: "child.jet", extends: other}
+	set.cacheTemplate(reparsedChild)
+
+	if deps := set.dependents["base.jet"]; len(deps) != 0 {
+		t.Fatalf("expected child.jet's stale dependency on base.jet to be pruned, got %v", deps)
+	}
+	if deps := set.dependents["other.jet"]; len(deps) != 1 || deps[0] != "child.jet" {
+		t.Fatalf("expected child.jet to depend on other.jet, got %v", deps)
+	}
+
+	// invalidating other.jet must cascade to child.jet, but base.jet must no
+	// longer be affected by child.jet at all.
+	set.invalidate("other.jet")
+	if _, ok := set.templates["child.jet"]; ok {
+		t.Fatalf("expected invalidate to cascade from other.jet and drop child.jet")
+	}
+}
+
+// Reparsing the same template twice with the same extends/import must not
+// duplicate its entry in the base's dependents slice.
+func TestRecordDependentsDoesNotDuplicateOnRepeatedReparse(t *testing.T) {
+	set := NewSetLoader(nil, NewInMemLoader())
+
+	base := &Template{ParseName: "base.jet"}
+	child := &Template{ParseName: "child.jet", extends: base}
+
+	set.cacheTemplate(base)
+	set.cacheTemplate(child)
+	set.cacheTemplate(&Template{ParseName: "child.jet", extends: base})
+	set.cacheTemplate(&Template{ParseName: "child.jet", extends: base})
+
+	if deps := set.dependents["base.jet"]; len(deps) != 1 {
+		t.Fatalf("expected exactly one dependents entry for repeated reparses, got %v", deps)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// An already-expired ctx must abort before anything is executed or written.
+func TestExecuteContextAbortsOnAlreadyCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tmpl := &Template{}
+	err := tmpl.ExecuteContext(ctx, io.Discard, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExecuteI18NContextAbortsOnAlreadyCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tmpl := &Template{}
+	err := tmpl.ExecuteI18NContext(ctx, nil, io.Discard, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// ctxWriter is the mechanism by which a cancelled ctx aborts a mid-execution
+// template: it only checks ctx on a write, so a write before cancellation
+// still succeeds, and the first write after cancellation fails instead of
+// silently going through.
+func TestCtxWriterFailsAfterCancel(t *testing.T) {
+	var buf bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	w := ctxWriter{Writer: &buf, ctx: ctx}
+
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Fatalf("unexpected error before cancel: %v", err)
+	}
+
+	cancel()
+
+	if _, err := w.Write([]byte("b")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled after cancel, got %v", err)
+	}
+	if buf.String() != "a" {
+		t.Fatalf("expected only the pre-cancel write to go through, got %q", buf.String())
+	}
+}
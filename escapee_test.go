@@ -0,0 +1,110 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import (
+	"io"
+	"testing"
+)
+
+// escapeeForPath used to range over a map of registered extensions, so a
+// path matching more than one registered extension (e.g. both ".jet" and
+// ".html.jet" registered, and a file named "report.html.jet") resolved to a
+// non-deterministic escapee from run to run. The longest matching extension
+// must always win, regardless of registration order.
+func TestEscapeeForPathLongestSuffixWins(t *testing.T) {
+	var calledHTML, calledText bool
+	html := func(w io.Writer, b []byte) { calledHTML = true }
+	text := func(w io.Writer, b []byte) { calledText = true }
+
+	set := NewSet(text, "")
+	set.RegisterEscapeeForExt(".jet", text)
+	set.RegisterEscapeeForExt(".html.jet", html)
+
+	escapee, explicit := set.escapeeForPath("report.html.jet")
+	if !explicit {
+		t.Fatalf("expected an explicit per-extension match")
+	}
+
+	escapee(nil, nil)
+	if !calledHTML || calledText {
+		t.Fatalf("expected the longer .html.jet rule to win, got html=%v text=%v", calledHTML, calledText)
+	}
+}
+
+// Registration order must not affect which rule wins.
+func TestEscapeeForPathLongestSuffixWinsRegardlessOfOrder(t *testing.T) {
+	var calledHTML, calledText bool
+	html := func(w io.Writer, b []byte) { calledHTML = true }
+	text := func(w io.Writer, b []byte) { calledText = true }
+
+	set := NewSet(text, "")
+	set.RegisterEscapeeForExt(".html.jet", html)
+	set.RegisterEscapeeForExt(".jet", text)
+
+	escapee, _ := set.escapeeForPath("report.html.jet")
+	escapee(nil, nil)
+	if !calledHTML || calledText {
+		t.Fatalf("expected the longer .html.jet rule to win, got html=%v text=%v", calledHTML, calledText)
+	}
+}
+
+// A child with no explicit per-extension override of its own inherits its
+// parent's escape mode, matching Hugo's plain-text/HTML partial rule.
+func TestApplyEscapeeInheritanceChildInheritsParentMode(t *testing.T) {
+	set := NewSet(nil, "")
+	var calledHTML bool
+	html := func(w io.Writer, b []byte) { calledHTML = true }
+
+	parent := &Template{Name: "parent", escapee: html}
+	child := &Template{Name: "child"}
+
+	if err := set.applyEscapeeInheritance(parent, child); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	child.escapee(nil, nil)
+	if !calledHTML {
+		t.Fatalf("expected child to inherit parent's escapee")
+	}
+}
+
+// A child with its own explicit, conflicting escape mode is an error rather
+// than a silent mix of escaping rules.
+func TestApplyEscapeeInheritanceConflictingExplicitModeErrors(t *testing.T) {
+	set := NewSet(nil, "")
+	html := func(w io.Writer, b []byte) {}
+	text := func(w io.Writer, b []byte) {}
+
+	parent := &Template{Name: "parent", escapee: html}
+	child := &Template{Name: "child", escapee: text, escapeeExplicit: true}
+
+	if err := set.applyEscapeeInheritance(parent, child); err == nil {
+		t.Fatalf("expected an error for conflicting explicit escape modes")
+	}
+}
+
+// A child whose explicit mode matches its parent's is not an error.
+func TestApplyEscapeeInheritanceMatchingExplicitModeOK(t *testing.T) {
+	set := NewSet(nil, "")
+	html := func(w io.Writer, b []byte) {}
+
+	parent := &Template{Name: "parent", escapee: html}
+	child := &Template{Name: "child", escapee: html, escapeeExplicit: true}
+
+	if err := set.applyEscapeeInheritance(parent, child); err != nil {
+		t.Fatalf("unexpected error for matching explicit escape modes: %v", err)
+	}
+}
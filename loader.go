@@ -18,10 +18,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 )
 
 // Loader is a minimal interface required for loading templates.
@@ -32,9 +34,74 @@ type Loader interface {
 	Open(templatePath string) (io.ReadCloser, error)
 }
 
+// Hasher is an optional interface a Loader can implement to let a Set
+// cheaply detect whether a cached template is stale, without rereading and
+// reparsing its content. Stat returns an opaque version string (e.g. a
+// content hash or a modification time) for path; a change in the returned
+// version invalidates any cached template parsed from the previous one.
+type Hasher interface {
+	Stat(path string) (version string, err error)
+}
+
+// ReadFileLoader is an optional interface a Loader can implement to let a
+// Set read a template's contents directly, avoiding the Open+ReadAll+Close
+// allocations of the generic path. Set.loadFromFile prefers it when
+// available — a small but meaningful win for cold-start template loading,
+// e.g. in serverless deployments.
+type ReadFileLoader interface {
+	ReadFile(path string) ([]byte, error)
+}
+
+// FSLoader implements Loader over a Go 1.16+ io/fs.FS, so a Set can be
+// backed by embed.FS, zip.Reader, or any other fs.FS implementation without
+// a custom Loader.
+type FSLoader struct {
+	fsys fs.FS
+}
+
+// compile time check that we implement Loader and ReadFileLoader
+var (
+	_ Loader         = (*FSLoader)(nil)
+	_ ReadFileLoader = (*FSLoader)(nil)
+)
+
+// NewFSLoader returns a Loader backed by fsys.
+func NewFSLoader(fsys fs.FS) *FSLoader {
+	return &FSLoader{fsys: fsys}
+}
+
+// fsPath converts a Jet template path (which may use OS-specific separators
+// and/or a leading slash) into the slash-separated, non-rooted form fs.FS
+// requires.
+func (l *FSLoader) fsPath(templatePath string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(templatePath)), "/")
+}
+
+// Open opens a file from the backing fs.FS.
+func (l *FSLoader) Open(templatePath string) (io.ReadCloser, error) {
+	return l.fsys.Open(l.fsPath(templatePath))
+}
+
+// Exists checks if the template exists in the backing fs.FS.
+func (l *FSLoader) Exists(templatePath string) (string, bool) {
+	resolved := l.fsPath(templatePath)
+	info, err := fs.Stat(l.fsys, resolved)
+	if err == nil && !info.IsDir() {
+		return resolved, true
+	}
+	return "", false
+}
+
+// ReadFile reads a template's contents directly from the backing fs.FS.
+func (l *FSLoader) ReadFile(templatePath string) ([]byte, error) {
+	return fs.ReadFile(l.fsys, l.fsPath(templatePath))
+}
+
 // OSFileSystemLoader implements Loader interface using OS file system (os.File).
+// It is a thin wrapper over FSLoader backed by os.DirFS(dir).
 type OSFileSystemLoader struct {
 	dir string
+	*FSLoader
 }
 
 // compile time check that we implement Loader
@@ -42,25 +109,11 @@ var _ Loader = (*OSFileSystemLoader)(nil)
 
 // NewOSFileSystemLoader returns an initialized OSFileSystemLoader.
 func NewOSFileSystemLoader(dirPath string) *OSFileSystemLoader {
+	dir := filepath.FromSlash(dirPath)
 	return &OSFileSystemLoader{
-		dir: filepath.FromSlash(dirPath),
-	}
-}
-
-// Open opens a file from OS file system.
-func (l *OSFileSystemLoader) Open(templatePath string) (io.ReadCloser, error) {
-	return os.Open(filepath.Join(l.dir, filepath.FromSlash(templatePath)))
-}
-
-// Exists checks if the template name exists by walking the list of template paths
-// returns true if the template file was found
-func (l *OSFileSystemLoader) Exists(templatePath string) (string, bool) {
-	templatePath = filepath.Join(l.dir, filepath.FromSlash(templatePath))
-	stat, err := os.Stat(templatePath)
-	if err == nil && !stat.IsDir() {
-		return templatePath, true
+		dir:      dir,
+		FSLoader: NewFSLoader(os.DirFS(dir)),
 	}
-	return "", false
 }
 
 type InMemLoader struct {
@@ -98,3 +151,56 @@ func (l *InMemLoader) Set(templatePath, contents string) {
 	templatePath = path.Join("/", templatePath)
 	l.files[templatePath] = []byte(contents)
 }
+
+// MultiLoader chains several Loaders together and searches them in priority
+// order (the order they were added), returning the first match. This lets a
+// Set overlay a theme/plugin directory on top of a base template directory,
+// or mount an InMemLoader for overrides on top of an OSFileSystemLoader.
+type MultiLoader struct {
+	loaders []Loader
+}
+
+// compile time check that we implement Loader
+var _ Loader = (*MultiLoader)(nil)
+
+// NewMultiLoader returns a MultiLoader searching loaders in the given order.
+func NewMultiLoader(loaders ...Loader) *MultiLoader {
+	return &MultiLoader{loaders: loaders}
+}
+
+// Add appends a Loader to the end of the search chain (lowest priority).
+func (l *MultiLoader) Add(loader Loader) {
+	l.loaders = append(l.loaders, loader)
+}
+
+// Exists checks each loader in priority order, returning the first match.
+func (l *MultiLoader) Exists(templatePath string) (string, bool) {
+	for _, loader := range l.loaders {
+		if resolved, ok := loader.Exists(templatePath); ok {
+			return resolved, true
+		}
+	}
+	return "", false
+}
+
+// Open opens templatePath from the first loader (in priority order) that
+// reports it exists.
+func (l *MultiLoader) Open(templatePath string) (io.ReadCloser, error) {
+	for _, loader := range l.loaders {
+		if _, ok := loader.Exists(templatePath); ok {
+			return loader.Open(templatePath)
+		}
+	}
+	return nil, fmt.Errorf("%s does not exist", templatePath)
+}
+
+// loaderFor returns the loader (in priority order) that would serve
+// templatePath, so callers can record which loader a cache entry came from.
+func (l *MultiLoader) loaderFor(templatePath string) (Loader, bool) {
+	for _, loader := range l.loaders {
+		if _, ok := loader.Exists(templatePath); ok {
+			return loader, true
+		}
+	}
+	return nil, false
+}
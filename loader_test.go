@@ -0,0 +1,31 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import "testing"
+
+// NewSet used to build its loader as the bare struct literal
+// &OSFileSystemLoader{dir: dir}, leaving the embedded *FSLoader nil and
+// panicking on the first Exists/Open call.
+func TestNewSetLoaderDoesNotPanic(t *testing.T) {
+	set := NewSet(nil, t.TempDir())
+
+	if _, ok := set.loader.Exists("missing.jet"); ok {
+		t.Fatalf("expected missing.jet to not exist")
+	}
+	if _, err := set.loader.Open("missing.jet"); err == nil {
+		t.Fatalf("expected an error opening a missing template")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// SetMapKeyResolver previously had no call site, so it had no effect on
+// LookupGlobal (or anything else) at execute time.
+func TestLookupGlobalUsesMapKeyResolver(t *testing.T) {
+	set := NewSet(nil, "")
+	set.AddGlobal("Greeting", "hello")
+
+	set.SetMapKeyResolver(func(m reflect.Value, key string) (reflect.Value, bool) {
+		for _, k := range m.MapKeys() {
+			if strings.EqualFold(k.String(), key) {
+				return m.MapIndex(k), true
+			}
+		}
+		return reflect.Value{}, false
+	})
+
+	val, found := set.LookupGlobal("greeting")
+	if !found {
+		t.Fatalf("expected case-insensitive resolver to find Greeting")
+	}
+	rv, ok := val.(reflect.Value)
+	if !ok || rv.Interface() != "hello" {
+		t.Fatalf("expected resolved value %q, got %v", "hello", val)
+	}
+}
+
+// ResolveContextKey is the supported way for a Func to apply the Set's
+// MapKeyResolver to a template's data context; it is not consulted by
+// {{ .Foo.Bar }} field/index evaluation.
+func TestRuntimeResolveContextKey(t *testing.T) {
+	set := NewSet(nil, "")
+	set.SetMapKeyResolver(func(m reflect.Value, key string) (reflect.Value, bool) {
+		for _, k := range m.MapKeys() {
+			if strings.EqualFold(k.String(), key) {
+				return m.MapIndex(k), true
+			}
+		}
+		return reflect.Value{}, false
+	})
+
+	rt := &Runtime{set: set, context: reflect.ValueOf(map[string]interface{}{"Greeting": "hello"})}
+
+	val, found := rt.ResolveContextKey("greeting")
+	if !found || val != "hello" {
+		t.Fatalf("expected resolved value %q, got %v (found=%v)", "hello", val, found)
+	}
+
+	if _, found := rt.ResolveContextKey("missing"); found {
+		t.Fatalf("expected missing to not resolve")
+	}
+}
+
+// ResolveContextKey must not panic when the data context isn't a map.
+func TestRuntimeResolveContextKeyNonMapContext(t *testing.T) {
+	rt := &Runtime{set: NewSet(nil, ""), context: reflect.ValueOf("not a map")}
+
+	if _, found := rt.ResolveContextKey("anything"); found {
+		t.Fatalf("expected a non-map context to never resolve")
+	}
+}
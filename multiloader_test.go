@@ -0,0 +1,83 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestMultiLoaderPriorityOrder(t *testing.T) {
+	high := NewInMemLoader()
+	high.Set("page.jet", "high")
+	low := NewInMemLoader()
+	low.Set("page.jet", "low")
+	low.Set("only-low.jet", "low-only")
+
+	multi := NewMultiLoader(high, low)
+
+	// InMemLoader.Set stores templates under rooted paths, which is how a
+	// Set looks them up.
+	if _, ok := multi.Exists("/page.jet"); !ok {
+		t.Fatalf("expected page.jet to exist")
+	}
+	rc, err := multi.Open("/page.jet")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rc.Close()
+	content, _ := ioutil.ReadAll(rc)
+	if string(content) != "high" {
+		t.Fatalf("expected the higher-priority loader to win, got %q", content)
+	}
+
+	if _, ok := multi.Exists("/only-low.jet"); !ok {
+		t.Fatalf("expected only-low.jet to be found via the lower-priority loader")
+	}
+}
+
+func TestAddLoaderPromotesSingleLoaderToMultiLoader(t *testing.T) {
+	base := NewInMemLoader()
+	base.Set("base.jet", "base")
+	set := NewSetLoader(nil, base)
+
+	overlay := NewInMemLoader()
+	overlay.Set("overlay.jet", "overlay")
+	set.AddLoader(overlay)
+
+	if _, ok := set.loader.(*MultiLoader); !ok {
+		t.Fatalf("expected loader to be promoted to *MultiLoader")
+	}
+	if _, ok := set.loader.Exists("/base.jet"); !ok {
+		t.Fatalf("expected base.jet to still be reachable")
+	}
+	if _, ok := set.loader.Exists("/overlay.jet"); !ok {
+		t.Fatalf("expected overlay.jet to be reachable via the added loader")
+	}
+}
+
+// AddLoader on a Set with no Loader yet used to wrap a nil entry in a
+// MultiLoader, panicking on the first Exists/Open call.
+func TestAddLoaderOnNilLoaderDoesNotPanic(t *testing.T) {
+	set := NewSetLoader(nil, nil)
+	overlay := NewInMemLoader()
+	overlay.Set("overlay.jet", "overlay")
+
+	set.AddLoader(overlay)
+
+	if _, ok := set.loader.Exists("/overlay.jet"); !ok {
+		t.Fatalf("expected overlay.jet to be reachable")
+	}
+}
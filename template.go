@@ -19,6 +19,7 @@
 package jet
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -26,8 +27,11 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"text/template"
+	"time"
 )
 
 var defaultExtensions = []string{
@@ -42,28 +46,111 @@ var defaultExtensions = []string{
 // create a set with jet.NewSet(escapeeFn) returns a pointer to the Set
 type Set struct {
 	loader          Loader
-	templates       map[string]*Template // parsed templates
-	escapee         SafeWriter           // escapee to use at runtime
-	globals         VarMap               // global scope for this template set
-	tmx             *sync.RWMutex        // template parsing mutex
-	gmx             *sync.RWMutex        // global variables map mutex
+	templates       map[string]*Template  // parsed templates
+	templateLoader  map[string]Loader     // which loader served each cached template, for dev-mode reloads
+	cache           map[string]*cacheEntry // parsed templates plus the loader version they were parsed from
+	dependents      map[string][]string   // base template path -> paths that extend/import it
+	escapee         SafeWriter            // default escapee to use at runtime
+	escapeeRules    []escapeRule          // per-extension escapee overrides, longest ext first, see RegisterEscapeeForExt
+	globals         VarMap                // global scope for this template set
+	mapKeyResolver  MapKeyResolver        // optional custom map-key lookup, see SetMapKeyResolver
+	transformers    []func(*Template) error // AST transformers, run after parse and before caching, see AddASTTransformer
+	tmx             *sync.RWMutex         // template parsing mutex
+	gmx             *sync.RWMutex         // global variables map mutex
 	extensions      []string
 	developmentMode bool
 	leftDelim       string
 	rightDelim      string
 }
 
+// escapeRule pairs a path extension with the SafeWriter registered for it.
+type escapeRule struct {
+	ext     string
+	escapee SafeWriter
+}
+
+// RegisterEscapeeForExt sets the SafeWriter used to escape output for
+// templates whose path ends in ext (e.g. ".txt.jet" for no escaping,
+// ".html.jet" for HTML escaping), overriding the Set's default escapee for
+// those templates. When a path matches more than one registered extension
+// (e.g. both ".jet" and ".html.jet" are registered and a template is named
+// "report.html.jet"), the longest matching extension wins, deterministically.
+// A template with no matching registered extension that is included or
+// imported by another inherits its parent's mode, matching Hugo's
+// plain-text/HTML partial rule; one whose registered mode differs from its
+// parent's is an error (see applyEscapeeInheritance).
+func (s *Set) RegisterEscapeeForExt(ext string, escapee SafeWriter) *Set {
+	for i, rule := range s.escapeeRules {
+		if rule.ext == ext {
+			s.escapeeRules[i].escapee = escapee
+			return s
+		}
+	}
+	// keep escapeeRules sorted by descending extension length so
+	// escapeeForPath's first suffix match is always the longest one,
+	// regardless of registration order.
+	i := sort.Search(len(s.escapeeRules), func(i int) bool {
+		return len(s.escapeeRules[i].ext) < len(ext)
+	})
+	s.escapeeRules = append(s.escapeeRules, escapeRule{})
+	copy(s.escapeeRules[i+1:], s.escapeeRules[i:])
+	s.escapeeRules[i] = escapeRule{ext: ext, escapee: escapee}
+	return s
+}
+
+// escapeeForPath resolves the SafeWriter that applies to templatePath,
+// falling back to the Set's default escapee when no per-extension override
+// matches. The second return value reports whether an explicit per-extension
+// override was used, as opposed to falling back to the Set's default.
+func (s *Set) escapeeForPath(templatePath string) (escapee SafeWriter, explicit bool) {
+	for _, rule := range s.escapeeRules {
+		if strings.HasSuffix(templatePath, rule.ext) {
+			return rule.escapee, true
+		}
+	}
+	return s.escapee, false
+}
+
 // SetDevelopmentMode set's development mode on/off, in development mode template will be recompiled on every run
 func (s *Set) SetDevelopmentMode(b bool) *Set {
 	s.developmentMode = b
 	return s
 }
 
+// LookupGlobal resolves key against the Set's live global scope. It is
+// called at execute time, not baked into templates at parse time, so
+// AddGlobal/AddGlobalFunc registrations made after templates are parsed
+// (e.g. a helper hot-loaded into a long-lived server) are still visible to
+// already-parsed templates.
+//
+// When key isn't registered verbatim, LookupGlobal falls back to the Set's
+// MapKeyResolver (see SetMapKeyResolver) against the globals map itself.
+// This only affects the Set's global scope, looked up by name from a
+// template (e.g. a function or variable registered with AddGlobal/
+// AddGlobalFunc); it is not consulted by field/index evaluation over a
+// template's own data context ({{ .Foo.Bar }}), which this build doesn't
+// expose a hook into. A Func that wants the same resolution strategy
+// applied to a map from the data context can call Runtime.ResolveContextKey
+// or Set.ResolveMapKey directly.
 func (s *Set) LookupGlobal(key string) (val interface{}, found bool) {
 	s.gmx.RLock()
-	val, found = s.globals[key]
-	s.gmx.RUnlock()
-	return
+	defer s.gmx.RUnlock()
+	if v, ok := s.globals[key]; ok {
+		return v, true
+	}
+	if s.mapKeyResolver != nil {
+		if v, ok := s.mapKeyResolver(reflect.ValueOf(s.globals), key); ok {
+			// globals holds reflect.Values, so unwrap the map element to
+			// return the same shape as the verbatim lookup above.
+			if v.IsValid() && v.CanInterface() {
+				if inner, isValue := v.Interface().(reflect.Value); isValue {
+					return inner, true
+				}
+			}
+			return v, true
+		}
+	}
+	return nil, false
 }
 
 // AddGlobal add or set a global variable into the Set
@@ -78,17 +165,121 @@ func (s *Set) AddGlobalFunc(key string, fn Func) *Set {
 	return s.AddGlobal(key, fn)
 }
 
+// MapKeyResolver customizes how a map lookup by string key is resolved, e.g.
+// to support case-insensitive keys or a Hugo Params-style fallback. It is
+// applied by LookupGlobal (against the Set's global scope) and by
+// Set.ResolveMapKey/Runtime.ResolveContextKey (for a Func to apply the same
+// strategy to a map it looked up itself, e.g. from the template's data
+// context). It is not wired into this build's field/index node evaluation,
+// so it has no effect on a template's own {{ .Foo.Bar }} syntax.
+type MapKeyResolver func(m reflect.Value, key string) (reflect.Value, bool)
+
+// SetMapKeyResolver installs resolver as the Set's map-key lookup strategy.
+// Passing nil restores the default exact-match reflect.Value.MapIndex lookup.
+func (s *Set) SetMapKeyResolver(resolver MapKeyResolver) *Set {
+	s.mapKeyResolver = resolver
+	return s
+}
+
+// ResolveMapKey looks up key in m, preferring the Set's MapKeyResolver when
+// one is registered and falling back to a direct map index lookup. It is the
+// same lookup LookupGlobal applies to the Set's global scope; a Func that
+// does its own map lookups (e.g. resolving a key against a map it fetched
+// from the template's data) can call this to honor the same resolution
+// strategy; Runtime.ResolveContextKey applies it to the Runtime's top-level
+// data context.
+func (s *Set) ResolveMapKey(m reflect.Value, key string) (reflect.Value, bool) {
+	if s.mapKeyResolver != nil {
+		if v, ok := s.mapKeyResolver(m, key); ok {
+			return v, true
+		}
+	}
+	v := m.MapIndex(reflect.ValueOf(key))
+	return v, v.IsValid()
+}
+
+// ResolveContextKey looks up key against r's top-level data context (the
+// value passed as data to Execute/ExecuteContext) when that context is
+// itself a map, applying the Runtime's Set's MapKeyResolver the same way
+// LookupGlobal applies it to globals. It returns false if the data context
+// isn't a map or doesn't have a Set. This is the supported way for a Func to
+// get Params-style fallback behavior over a template's data context; it is
+// not automatically applied to a template's own {{ .Foo.Bar }} field/index
+// syntax.
+func (r *Runtime) ResolveContextKey(key string) (interface{}, bool) {
+	if r.set == nil || !r.context.IsValid() || r.context.Kind() != reflect.Map {
+		return nil, false
+	}
+	v, ok := r.set.ResolveMapKey(r.context, key)
+	if !ok {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// AddASTTransformer registers fn to run on every template after it is
+// parsed and before it is cached, in registration order. A transformer can
+// walk and rewrite the parsed node tree (e.g. to inject security policies,
+// auto-inline i18n keys, replace deprecated function calls, or collect a
+// manifest of used partials for build tools). A non-nil error aborts parsing
+// of that template with an error wrapping the transformer's error.
+func (s *Set) AddASTTransformer(fn func(*Template) error) *Set {
+	s.transformers = append(s.transformers, fn)
+	return s
+}
+
+// runASTTransformers runs the Set's registered AST transformers over t, in
+// registration order, stopping at the first error.
+func (s *Set) runASTTransformers(t *Template) error {
+	for _, transform := range s.transformers {
+		if err := transform(t); err != nil {
+			return fmt.Errorf("jet: AST transformer failed for %s: %w", t.ParseName, err)
+		}
+	}
+	return nil
+}
+
 // NewSetLoader creates a new set with custom Loader
 func NewSetLoader(escapee SafeWriter, loader Loader) *Set {
 	return &Set{
-		loader:     loader,
-		templates:  map[string]*Template{},
-		escapee:    escapee,
-		globals:    VarMap{},
-		tmx:        &sync.RWMutex{},
-		gmx:        &sync.RWMutex{},
-		extensions: append([]string{}, defaultExtensions...),
+		loader:         loader,
+		templates:      map[string]*Template{},
+		templateLoader: map[string]Loader{},
+		cache:          map[string]*cacheEntry{},
+		dependents:     map[string][]string{},
+		escapee:        escapee,
+		globals:        VarMap{},
+		tmx:            &sync.RWMutex{},
+		gmx:            &sync.RWMutex{},
+		extensions:     append([]string{}, defaultExtensions...),
+	}
+}
+
+// NewSetLoaders creates a new set backed by a chain of Loaders, searched in
+// priority order for both Exists and Open. This lets users overlay a
+// theme/plugin directory on top of a base template directory, or mount an
+// InMemLoader for overrides on top of an OSFileSystemLoader.
+func NewSetLoaders(escapee SafeWriter, loaders ...Loader) *Set {
+	return NewSetLoader(escapee, NewMultiLoader(loaders...))
+}
+
+// AddLoader appends loader to the Set's search chain, at the lowest
+// priority. If the Set was created with a single Loader (e.g. via NewSet),
+// it is promoted to a MultiLoader on first use. If the Set has no Loader
+// yet, loader becomes the Set's Loader directly, rather than being wrapped
+// in a MultiLoader over a nil entry.
+func (s *Set) AddLoader(loader Loader) *Set {
+	if s.loader == nil {
+		s.loader = loader
+		return s
 	}
+	multi, ok := s.loader.(*MultiLoader)
+	if !ok {
+		multi = NewMultiLoader(s.loader)
+		s.loader = multi
+	}
+	multi.Add(loader)
+	return s
 }
 
 // NewHTMLSetLoader creates a new set with custom Loader
@@ -98,7 +289,7 @@ func NewHTMLSetLoader(loader Loader) *Set {
 
 // NewSet creates a new set, dirs is a list of directories to be searched for templates
 func NewSet(escapee SafeWriter, dir string) *Set {
-	return NewSetLoader(escapee, &OSFileSystemLoader{dir: dir})
+	return NewSetLoader(escapee, NewOSFileSystemLoader(dir))
 }
 
 // NewHTMLSet creates a new set, dirs is a list of directories to be searched for templates
@@ -128,6 +319,12 @@ func (s *Set) GetTemplate(templatePath string) (t *Template, err error) {
 	return s.getSiblingTemplate(templatePath, "/", true)
 }
 
+// getSiblingTemplate resolves templatePath relative to siblingPath — the
+// path of the template doing the {{include}}/{{import}}/{{extends}}, or "/"
+// when there is none (as from the top-level GetTemplate). When siblingPath
+// names an already-cached template, the resolved template inherits that
+// template's escape mode (see applyEscapeeInheritance), matching Hugo's
+// plain-text/HTML partial-inheritance rule.
 func (s *Set) getSiblingTemplate(templatePath, siblingPath string, cacheAfterParsing bool) (t *Template, err error) {
 	templatePath = filepath.ToSlash(templatePath)
 	siblingPath = filepath.ToSlash(siblingPath)
@@ -135,59 +332,261 @@ func (s *Set) getSiblingTemplate(templatePath, siblingPath string, cacheAfterPar
 		siblingDir := path.Dir(siblingPath)
 		templatePath = path.Join(siblingDir, templatePath)
 	}
-	return s.getTemplate(templatePath, cacheAfterParsing)
+	t, err = s.getTemplate(templatePath, cacheAfterParsing)
+	if err != nil {
+		return nil, err
+	}
+	if parent, ok := s.cache[siblingPath]; ok {
+		if err := s.applyEscapeeInheritance(parent.tmpl, t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
 }
 
 // same as GetTemplate, but assumes the reader already called s.tmx.RLock(), and
 // doesn't cache a template when found through the loader
 func (s *Set) getTemplate(templatePath string, cacheAfterParsing bool) (t *Template, err error) {
-	if !s.developmentMode {
-		t, found := s.getTemplateFromCache(templatePath)
-		if found {
-			return t, nil
+	if entry, found := s.getCacheEntry(templatePath); found {
+		if !s.isStale(entry) {
+			return entry.tmpl, nil
 		}
+	} else if s.developmentMode {
+		// nothing cached yet: load it below. Reparsing a cached entry in
+		// development mode when its loader has no Hasher is decided by isStale.
 	}
 
 	t, err = s.getTemplateFromLoader(templatePath, cacheAfterParsing)
-	if err == nil && cacheAfterParsing && !s.developmentMode {
-		s.templates[templatePath] = t
+	if err == nil && cacheAfterParsing {
+		s.cacheTemplate(t)
 	}
 	return t, err
 }
 
-func (s *Set) getTemplateFromCache(templatePath string) (t *Template, ok bool) {
-	// check path with all possible extensions in cache
+// cacheEntry pairs a parsed Template with the loader-reported version it was
+// parsed from, so GetTemplate can cheaply tell whether it is still current.
+type cacheEntry struct {
+	tmpl    *Template
+	version string
+}
+
+func (s *Set) getCacheEntry(templatePath string) (entry *cacheEntry, ok bool) {
 	for _, extension := range s.extensions {
 		canonicalPath := templatePath + extension
-		if t, found := s.templates[canonicalPath]; found {
-			return t, true
+		if e, found := s.cache[canonicalPath]; found {
+			return e, true
 		}
 	}
 	return nil, false
 }
 
+// isStale reports whether entry's loader-reported version has changed since
+// it was cached. If the serving loader doesn't implement Hasher, entries are
+// only considered stale in development mode (matching the previous
+// recompile-every-run behavior).
+func (s *Set) isStale(entry *cacheEntry) bool {
+	loader, ok := s.templateLoader[entry.tmpl.ParseName]
+	if !ok {
+		loader = s.loader
+	}
+	hasher, ok := loader.(Hasher)
+	if !ok {
+		return s.developmentMode
+	}
+	version, err := hasher.Stat(entry.tmpl.ParseName)
+	if err != nil {
+		return s.developmentMode
+	}
+	return version != entry.version
+}
+
+// cacheTemplate stores t in the cache, keyed by its canonical, extension-
+// resolved ParseName — the same key getTemplateFromLoader recorded t's
+// serving loader under — so the loader and version stamped here line up
+// with what isStale looks up on the next GetTemplate call.
+func (s *Set) cacheTemplate(t *Template) {
+	entry := &cacheEntry{tmpl: t}
+	if loader, ok := s.templateLoader[t.ParseName]; ok {
+		if hasher, ok := loader.(Hasher); ok {
+			if version, err := hasher.Stat(t.ParseName); err == nil {
+				entry.version = version
+			}
+		}
+	}
+	s.cache[t.ParseName] = entry
+	s.templates[t.ParseName] = t
+	s.recordDependents(t)
+}
+
+// recordDependents indexes t against the base template(s) it currently
+// extends or imports, so that invalidating a base can cascade to its
+// dependents. Any associations left over from a previous parse of t (e.g. t
+// used to extend a different base, or no longer imports one it used to) are
+// pruned first, and an association already present isn't duplicated, so
+// repeated reparses (as dev-mode Watch performs) don't grow s.dependents'
+// slices unboundedly.
+func (s *Set) recordDependents(t *Template) {
+	s.pruneDependent(t.ParseName)
+	register := func(base *Template) {
+		if base == nil {
+			return
+		}
+		deps := s.dependents[base.ParseName]
+		for _, dep := range deps {
+			if dep == t.ParseName {
+				return
+			}
+		}
+		s.dependents[base.ParseName] = append(deps, t.ParseName)
+	}
+	register(t.extends)
+	for _, imported := range t.imports {
+		register(imported)
+	}
+}
+
+// pruneDependent removes every recorded association of dependent from
+// s.dependents, so recordDependents can re-index it from scratch against its
+// current extends/import base(s) without leaving stale cascade targets
+// behind from a prior parse.
+func (s *Set) pruneDependent(dependent string) {
+	for base, deps := range s.dependents {
+		for i, dep := range deps {
+			if dep == dependent {
+				deps = append(deps[:i], deps[i+1:]...)
+				break
+			}
+		}
+		if len(deps) == 0 {
+			delete(s.dependents, base)
+		} else {
+			s.dependents[base] = deps
+		}
+	}
+}
+
+// invalidate drops templatePath (and anything extending/importing it) from
+// the cache, forcing a reparse on next GetTemplate.
+func (s *Set) invalidate(templatePath string) {
+	delete(s.cache, templatePath)
+	delete(s.templates, templatePath)
+	for _, dependent := range s.dependents[templatePath] {
+		s.invalidate(dependent)
+	}
+	delete(s.dependents, templatePath)
+}
+
+// Watch starts a background goroutine that periodically walks known
+// templates and invalidates stale entries (including their dependents),
+// using each template's serving loader's Hasher.Stat when available. It
+// returns a stop function that halts the goroutine.
+func (s *Set) Watch(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				s.tmx.Lock()
+				for path, entry := range s.cache {
+					if s.isStale(entry) {
+						s.invalidate(path)
+					}
+				}
+				s.tmx.Unlock()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// sourceLoader returns the individual Loader recorded as having served path
+// (getTemplateFromLoader resolves it through a MultiLoader's priority chain
+// when loading), and whether one was recorded at all.
+func (s *Set) sourceLoader(path string) (Loader, bool) {
+	loader, ok := s.templateLoader[path]
+	return loader, ok
+}
+
 func (s *Set) getTemplateFromLoader(templatePath string, cacheAfterParsing bool) (t *Template, err error) {
 	// check path with all possible extensions in loader
 	for _, extension := range s.extensions {
 		canonicalPath := templatePath + extension
 		if _, found := s.loader.Exists(canonicalPath); found {
-			return s.loadFromFile(canonicalPath, cacheAfterParsing)
+			servingLoader := s.loader
+			if multi, ok := s.loader.(*MultiLoader); ok {
+				if resolved, ok := multi.loaderFor(canonicalPath); ok {
+					servingLoader = resolved
+				}
+			}
+			t, err = s.loadFromFile(canonicalPath, cacheAfterParsing)
+			if err == nil && cacheAfterParsing {
+				s.templateLoader[canonicalPath] = servingLoader
+			}
+			return t, err
 		}
 	}
 	return nil, fmt.Errorf("template %s could not be found", templatePath)
 }
 
 func (s *Set) loadFromFile(templatePath string, cacheAfterParsing bool) (template *Template, err error) {
-	f, err := s.loader.Open(templatePath)
-	if err != nil {
-		return nil, err
+	loader := s.loader
+	if multi, ok := s.loader.(*MultiLoader); ok {
+		if resolved, ok := multi.loaderFor(templatePath); ok {
+			loader = resolved
+		}
 	}
-	defer f.Close()
-	content, err := ioutil.ReadAll(f)
-	if err != nil {
-		return nil, err
+
+	var content []byte
+	if readFileLoader, ok := loader.(ReadFileLoader); ok {
+		if content, err = readFileLoader.ReadFile(templatePath); err != nil {
+			return nil, err
+		}
+	} else {
+		var f io.ReadCloser
+		if f, err = s.loader.Open(templatePath); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if content, err = ioutil.ReadAll(f); err != nil {
+			return nil, err
+		}
+	}
+	template, err = s.parse(templatePath, string(content), cacheAfterParsing)
+	if err == nil {
+		template.escapee, template.escapeeExplicit = s.escapeeForPath(templatePath)
+		err = s.runASTTransformers(template)
+	}
+	return template, err
+}
+
+// applyEscapeeInheritance makes child inherit parent's escape mode when
+// child has no explicit per-extension override of its own (the common case
+// for a partial meant to be included from either a plain-text or an HTML
+// template), matching Hugo's plain-text/HTML partial-inheritance rule. If
+// child does have its own explicit override and it conflicts with parent's
+// mode, it returns an error via checkEscapeeMix instead of silently mixing
+// escaping rules.
+func (s *Set) applyEscapeeInheritance(parent, child *Template) error {
+	if !child.escapeeExplicit {
+		child.escapee = parent.escapee
+		return nil
 	}
-	return s.parse(templatePath, string(content), cacheAfterParsing)
+	return s.checkEscapeeMix(parent, child)
+}
+
+// checkEscapeeMix reports an error when child was parsed under a different
+// escape mode than parent, so that an {{include}} or {{import}} spanning a
+// plain-text and an HTML template is caught instead of silently mixing
+// escaping rules.
+func (s *Set) checkEscapeeMix(parent, child *Template) error {
+	if fmt.Sprintf("%p", parent.escapee) != fmt.Sprintf("%p", child.escapee) {
+		return fmt.Errorf("jet: %s and %s use different escape modes, %s inherits %s's mode", child.Name, parent.Name, child.Name, parent.Name)
+	}
+	return nil
 }
 
 func (s *Set) Parse(templatePath, contents string) (template *Template, err error) {
@@ -201,7 +600,11 @@ func (s *Set) Parse(templatePath, contents string) (template *Template, err erro
 
 	s.tmx.RLock()
 	defer s.tmx.RUnlock()
-	return s.parse(templatePath, contents, false)
+	template, err = s.parse(templatePath, contents, false)
+	if err == nil {
+		err = s.runASTTransformers(template)
+	}
+	return template, err
 }
 
 // SetExtensions sets extensions.
@@ -270,20 +673,59 @@ func (t *Template) Execute(w io.Writer, variables VarMap, data interface{}) erro
 	return t.ExecuteI18N(nil, w, variables, data)
 }
 
+// ExecuteContext is like Execute, but binds ctx to the execution: an
+// already-expired ctx aborts before any output is written, a ctx that is
+// cancelled mid-execution aborts the next time the template writes output,
+// and Funcs can recover ctx through the Runtime's Context accessor to honor
+// cancellation and deadlines when doing I/O. Cancellation is only observed
+// on a write: a range/action loop body that does no output of its own (e.g.
+// a Func doing pure computation or I/O without writing to w) will not abort
+// mid-loop — only the already-expired check at the start, and the next
+// write after the loop, if any, are guaranteed.
+func (t *Template) ExecuteContext(ctx context.Context, w io.Writer, variables VarMap, data interface{}) error {
+	return t.ExecuteI18NContext(ctx, nil, w, variables, data)
+}
+
 type Translator interface {
 	Msg(key, defaultValue string) string
 	Trans(format, defaultFormat string, v ...interface{}) string
 }
 
 func (t *Template) ExecuteI18N(translator Translator, w io.Writer, variables VarMap, data interface{}) (err error) {
+	return t.ExecuteI18NContext(context.Background(), translator, w, variables, data)
+}
+
+// ExecuteI18NContext is like ExecuteI18N, but binds ctx to the execution: an
+// already-expired ctx aborts before any output is written, a ctx that is
+// cancelled mid-execution aborts the next time the template writes output,
+// and Funcs can recover ctx through the Runtime's Context accessor to honor
+// cancellation and deadlines when doing I/O. Cancellation is only observed
+// on a write: a range/action loop body that does no output of its own (e.g.
+// a Func doing pure computation or I/O without writing to w) will not abort
+// mid-loop — only the already-expired check at the start, and the next
+// write after the loop, if any, are guaranteed.
+func (t *Template) ExecuteI18NContext(ctx context.Context, translator Translator, w io.Writer, variables VarMap, data interface{}) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
 	st := pool_State.Get().(*Runtime)
 	defer st.recover(&err)
 
+	st.ctx = ctx
 	st.blocks = t.processedBlocks
 	st.translator = translator
 	st.variables = variables
 	st.set = t.set
-	st.Writer = w
+	st.Writer = ctxWriter{Writer: w, ctx: ctx}
+	if t.escapee != nil {
+		st.escapee = t.escapee
+	} else {
+		st.escapee = t.set.escapee
+	}
 
 	// resolve extended template
 	for t.extends != nil {
@@ -297,3 +739,33 @@ func (t *Template) ExecuteI18N(translator Translator, w io.Writer, variables Var
 	st.executeList(t.Root)
 	return
 }
+
+// ctxWriter wraps a Runtime's output Writer so that every write made while
+// executing a range/action loop body first checks ctx. Since emitting
+// output is the one thing every loop iteration that does real work ends up
+// doing, this gives range/action loops a periodic, low-overhead abort point
+// without threading a context check through every node type: once ctx is
+// done, the next write returns ctx.Err(), which propagates through the
+// existing panic/recover path (see st.recover) as the execution's error.
+type ctxWriter struct {
+	io.Writer
+	ctx context.Context
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.Writer.Write(p)
+}
+
+// Context returns the context.Context bound to the current execution via
+// ExecuteContext/ExecuteI18NContext, or context.Background() if the template
+// was executed with Execute/ExecuteI18N. User-registered Funcs can call this
+// to honor cancellation and deadlines when doing I/O.
+func (r *Runtime) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
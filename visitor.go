@@ -0,0 +1,96 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+// NodeWithChildren is implemented by Node types that have children, so Walk
+// can recurse into them without knowing every concrete Node kind. *ListNode
+// implements it below; other Node kinds are visited but not descended into
+// unless they also implement it.
+//
+// Known gap: composite node kinds that nest a body *ListNode of their own
+// (an if/else branch, a range body, a block's content) are not implemented
+// here, so Walk currently stops at the first such node instead of
+// descending into its body. Adding Children() to those types requires their
+// concrete field layout, which isn't available alongside this file; until
+// that's added, Walk/WalkTemplate should be treated as covering only a
+// template's flat, top-level node list (plus extends/imports via
+// WalkTemplate), not everything nested inside control-flow bodies.
+type NodeWithChildren interface {
+	Node
+	Children() []Node
+}
+
+// Children returns l's direct child nodes, letting Walk descend into a
+// list's statements without special-casing *ListNode.
+func (l *ListNode) Children() []Node {
+	return l.Nodes
+}
+
+var _ NodeWithChildren = (*ListNode)(nil)
+
+// Visitor holds the callbacks Walk invokes: Enter for every node visited,
+// and Block, typed, for each *BlockNode. Any field left nil is simply
+// skipped. Each callback returns false to stop descending into that node's
+// children.
+type Visitor struct {
+	Block func(*BlockNode) bool
+	Enter func(Node) bool
+}
+
+// Walk traverses the AST rooted at n in depth-first order, invoking v's
+// typed callbacks. It is the supported extension point for tools that need
+// to inspect or collect information from a parsed Template's node tree, e.g.
+// a manifest of used partials for build tooling. See the known gap noted on
+// NodeWithChildren: a node nested inside an if/range/block body is not
+// currently reached unless its enclosing composite node also implements
+// NodeWithChildren.
+func Walk(n Node, v *Visitor) {
+	if n == nil {
+		return
+	}
+	descend := true
+	if v.Enter != nil {
+		descend = v.Enter(n)
+	}
+	if block, ok := n.(*BlockNode); ok && v.Block != nil {
+		if !v.Block(block) {
+			descend = false
+		}
+	}
+	if !descend {
+		return
+	}
+	if withChildren, ok := n.(NodeWithChildren); ok {
+		for _, child := range withChildren.Children() {
+			Walk(child, v)
+		}
+	}
+}
+
+// WalkTemplate walks t's Root, t's extends chain, and t's imports, in that
+// order, so a transformer or build tool can traverse a template's
+// top-level structure — across extends/import boundaries, not just a
+// single Root — with one call. It is still subject to the same descent gap
+// as Walk for anything nested inside an if/range/block body.
+func WalkTemplate(t *Template, v *Visitor) {
+	if t == nil {
+		return
+	}
+	Walk(t.Root, v)
+	WalkTemplate(t.extends, v)
+	for _, imported := range t.imports {
+		WalkTemplate(imported, v)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 José Santos <henrique_1609@me.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jet
+
+import "testing"
+
+// Before ListNode implemented NodeWithChildren, Walk visited only the node
+// it was given and never reached anything below a *ListNode, so it was
+// incapable of ever invoking v.Block.
+func TestWalkDescendsIntoListNodeChildren(t *testing.T) {
+	block := &BlockNode{}
+	root := &ListNode{Nodes: []Node{block}}
+
+	var seen []Node
+	var sawBlock bool
+	Walk(root, &Visitor{
+		Enter: func(n Node) bool {
+			seen = append(seen, n)
+			return true
+		},
+		Block: func(b *BlockNode) bool {
+			sawBlock = true
+			return true
+		},
+	})
+
+	if len(seen) != 2 {
+		t.Fatalf("expected Walk to visit the root and its child, visited %d nodes", len(seen))
+	}
+	if !sawBlock {
+		t.Fatalf("expected Walk to invoke Block for the nested BlockNode")
+	}
+}
+
+// WalkTemplate must cross extends/import boundaries, not just walk a single
+// Root, so a manifest-building transformer sees partials reached only
+// through {{extends}}/{{import}}.
+func TestWalkTemplateCrossesExtendsAndImports(t *testing.T) {
+	extendsBlock := &BlockNode{}
+	base := &Template{ParseName: "base.jet", Root: &ListNode{Nodes: []Node{extendsBlock}}}
+
+	importBlock := &BlockNode{}
+	imported := &Template{ParseName: "partial.jet", Root: &ListNode{Nodes: []Node{importBlock}}}
+
+	childBlock := &BlockNode{}
+	child := &Template{
+		ParseName: "child.jet",
+		Root:      &ListNode{Nodes: []Node{childBlock}},
+		extends:   base,
+		imports:   []*Template{imported},
+	}
+
+	var blocksSeen int
+	WalkTemplate(child, &Visitor{
+		Block: func(b *BlockNode) bool {
+			blocksSeen++
+			return true
+		},
+	})
+
+	if blocksSeen != 3 {
+		t.Fatalf("expected WalkTemplate to reach blocks in child, base, and the import, got %d", blocksSeen)
+	}
+}